@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================================
+// LÍMITE DE TAMAÑO DE PETICIÓN
+// ============================================================================
+
+// maxRequestBytes acota el tamaño del body aceptado en /api/predict y
+// /api/predict/batch.
+var maxRequestBytes = int64(getEnvInt("MAX_REQUEST_BYTES", 1<<20)) // 1 MiB por defecto
+
+// MaxRecvSizeMiddleware envuelve el body de la petición con
+// http.MaxBytesReader, para que un body que exceda maxRequestBytes falle al
+// leerlo en vez de agotar memoria del servidor.
+func MaxRecvSizeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBytes)
+		c.Next()
+	}
+}
+
+// bindJSONWithSizeLimit intenta deserializar el body en obj, respondiendo un
+// 413 estructurado si el body excedió maxRequestBytes (ver MaxRecvSizeMiddleware)
+// o un 400 para cualquier otro error de parseo/validación. Devuelve true si
+// el bind tuvo éxito.
+func bindJSONWithSizeLimit(c *gin.Context, obj any) bool {
+	if err := c.ShouldBindJSON(obj); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"error":   "El cuerpo de la petición excede el tamaño máximo permitido",
+			})
+			return false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Datos de entrada inválidos",
+			"details": err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// ============================================================================
+// PREDICCIÓN EN LOTE
+// ============================================================================
+
+// maxBatchSize acota cuántos ítems acepta /api/predict/batch en una sola petición.
+var maxBatchSize = getEnvInt("MAX_BATCH_SIZE", 50)
+
+// batchWorkers acota cuántas llamadas concurrentes al servicio de IA lanza
+// una petición de batch.
+var batchWorkers = getEnvInt("BATCH_WORKERS", 10)
+
+// BatchPredictRequest representa el body de /api/predict/batch.
+type BatchPredictRequest struct {
+	Items []PredictRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+// BatchPredictResult representa el resultado de un ítem del batch,
+// preservando su posición en la lista de entrada.
+type BatchPredictResult struct {
+	Index    int              `json:"index"`
+	Success  bool             `json:"success"`
+	Response *PredictResponse `json:"response,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// BatchPredictHandler maneja POST /api/predict/batch: procesa cada ítem de
+// forma concurrente (con un worker pool acotado) y devuelve los resultados
+// en el mismo orden que la petición.
+func BatchPredictHandler(c *gin.Context) {
+	var req BatchPredictRequest
+	if !bindJSONWithSizeLimit(c, &req) {
+		return
+	}
+
+	if len(req.Items) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   fmt.Sprintf("el batch excede el máximo de %d ítems", maxBatchSize),
+		})
+		return
+	}
+
+	results := make([]BatchPredictResult, len(req.Items))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	workers := batchWorkers
+	if workers > len(req.Items) {
+		workers = len(req.Items)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				response, _, err := processPredictRequest(req.Items[i])
+				if err != nil {
+					results[i] = BatchPredictResult{Index: i, Success: false, Error: err.Error()}
+					continue
+				}
+				results[i] = BatchPredictResult{Index: i, Success: true, Response: response}
+			}
+		}()
+	}
+
+	for i := range req.Items {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"results": results,
+	})
+}
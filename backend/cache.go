@@ -0,0 +1,184 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ============================================================================
+// CACHÉ DE RESPUESTAS
+// ============================================================================
+
+// cacheTTL controla cuánto tiempo se sirve una respuesta desde caché antes de
+// volver a consultar al servicio de IA.
+var cacheTTL = getEnvDuration("CACHE_TTL_SECONDS", 10*time.Minute)
+
+// cacheEntry almacena una respuesta junto con su momento de expiración.
+type cacheEntry struct {
+	response  PredictResponse
+	expiresAt time.Time
+}
+
+// ResponseCache es un caché en memoria de respuestas de predicción, indexado
+// por la combinación (provincia, cantón, day, month).
+type ResponseCache struct {
+	mu      sync.RWMutex
+	entries map[string]cacheEntry
+	ttl     time.Duration
+	hits    uint64
+	misses  uint64
+}
+
+// NewResponseCache crea un ResponseCache vacío con el TTL indicado.
+func NewResponseCache(ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		entries: make(map[string]cacheEntry),
+		ttl:     ttl,
+	}
+}
+
+// Get busca una respuesta en caché para la clave dada. Devuelve false si no
+// existe o si ya expiró.
+func (c *ResponseCache) Get(key string) (PredictResponse, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		atomic.AddUint64(&c.misses, 1)
+		return PredictResponse{}, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	return entry.response, true
+}
+
+// Set guarda una respuesta en caché, reemplazando cualquier entrada previa
+// para la misma clave.
+func (c *ResponseCache) Set(key string, response PredictResponse) {
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{
+		response:  response,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	c.mu.Unlock()
+}
+
+// Stats devuelve el número de aciertos, fallos y entradas actuales del caché.
+func (c *ResponseCache) Stats() (hits, misses uint64, size int) {
+	c.mu.RLock()
+	size = len(c.entries)
+	c.mu.RUnlock()
+	return atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses), size
+}
+
+// responseCache es la instancia compartida usada por PredictHandler.
+var responseCache = NewResponseCache(cacheTTL)
+
+// cacheKey genera un digest estable para (provincia, canton, day, month), que
+// sirve tanto de clave de caché como de clave en los mapas de peak requests.
+func cacheKey(req PredictRequest) string {
+	raw := fmt.Sprintf("%s|%s|%d|%d", req.Provincia, req.Canton, req.Day, req.Month)
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// ============================================================================
+// PRECALENTAMIENTO DE CACHÉ (PEAK REQUESTS)
+// ============================================================================
+
+// peakRequests30 registra las peticiones vistas en el minuto :30 de cada hora,
+// para ser re-ejecutadas poco antes del próximo minuto :30.
+var peakRequests30 sync.Map
+
+// peakRequests60 registra las peticiones vistas en el minuto :00 de cada hora,
+// para ser re-ejecutadas poco antes de la próxima hora en punto.
+var peakRequests60 sync.Map
+
+// recordPeakRequest guarda la petición en el mapa de peak requests correcto
+// si la petición llegó en un minuto "pico" (:00 o :30).
+func recordPeakRequest(req PredictRequest) {
+	switch time.Now().Minute() {
+	case 0:
+		peakRequests60.Store(cacheKey(req), req)
+	case 30:
+		peakRequests30.Store(cacheKey(req), req)
+	}
+}
+
+// startPrefetchScheduler lanza un goroutine que, emulando el cron
+// "24 * * * *" y "54 * * * *", recalienta el caché de respuestas replicando
+// las peticiones observadas en el ciclo anterior antes de que llegue el pico
+// de tráfico.
+func startPrefetchScheduler() {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			switch time.Now().Minute() {
+			case 24:
+				prefetchPeak(&peakRequests30)
+			case 54:
+				prefetchPeak(&peakRequests60)
+			}
+		}
+	}()
+}
+
+// prefetchPeak recorre un mapa de peak requests, vuelve a llamar al servicio
+// de IA para cada una (repoblando el caché de respuestas), y vacía el mapa
+// para el siguiente ciclo.
+func prefetchPeak(m *sync.Map) {
+	m.Range(func(key, value any) bool {
+		req := value.(PredictRequest)
+		coords, err := GetCoordinates(req.Provincia, req.Canton)
+		if err != nil {
+			m.Delete(key)
+			return true
+		}
+
+		aiResponse, err := callAIServiceWithResilience("prefetch_"+key.(string), AIServiceRequest{
+			Latitude:  coords.Lat,
+			Longitude: coords.Lng,
+			Day:       req.Day,
+			Month:     req.Month,
+			Provincia: req.Provincia,
+			Canton:    req.Canton,
+		})
+		if err != nil {
+			log.Printf("Prefetch falló para %s/%s: %v", req.Provincia, req.Canton, err)
+			m.Delete(key)
+			return true
+		}
+
+		responseCache.Set(key.(string), PredictResponse{
+			Success:      aiResponse.Success,
+			Timestamp:    aiResponse.Timestamp,
+			Location:     aiResponse.Location,
+			Predictions:  aiResponse.Predictions,
+			ModelVersion: aiResponse.ModelVersion,
+			IsMock:       aiResponse.IsMock,
+		})
+		m.Delete(key)
+		return true
+	})
+}
+
+// getEnvDuration lee una variable de entorno como segundos enteros y la
+// convierte a time.Duration, devolviendo defaultValue si no está definida o
+// es inválida.
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	var seconds int
+	if _, err := fmt.Sscanf(value, "%d", &seconds); err != nil || seconds <= 0 {
+		return defaultValue
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheKeyStableAndDistinct(t *testing.T) {
+	a := PredictRequest{Provincia: "Guayas", Canton: "Guayaquil", Day: 15, Month: 6}
+	b := PredictRequest{Provincia: "Guayas", Canton: "Guayaquil", Day: 15, Month: 6}
+	if cacheKey(a) != cacheKey(b) {
+		t.Fatalf("cacheKey debería ser estable para la misma petición")
+	}
+
+	c := PredictRequest{Provincia: "Guayas", Canton: "Durán", Day: 15, Month: 6}
+	if cacheKey(a) == cacheKey(c) {
+		t.Fatalf("cacheKey no debería colisionar para cantones distintos")
+	}
+}
+
+func TestResponseCacheGetSetAndExpiry(t *testing.T) {
+	cache := NewResponseCache(20 * time.Millisecond)
+	key := "clave-de-prueba"
+
+	if _, ok := cache.Get(key); ok {
+		t.Fatalf("esperaba un miss antes de Set")
+	}
+
+	cache.Set(key, PredictResponse{Success: true, RequestID: "req_1"})
+
+	got, ok := cache.Get(key)
+	if !ok || !got.Success || got.RequestID != "req_1" {
+		t.Fatalf("esperaba un hit con la respuesta guardada, obtuve %+v, ok=%v", got, ok)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := cache.Get(key); ok {
+		t.Fatalf("esperaba que la entrada expirara tras el TTL")
+	}
+
+	hits, misses, size := cache.Stats()
+	if hits != 1 || misses != 2 {
+		t.Fatalf("esperaba 1 hit y 2 misses, obtuve hits=%d misses=%d", hits, misses)
+	}
+	if size != 1 {
+		t.Fatalf("esperaba 1 entrada almacenada (aunque expirada), obtuve %d", size)
+	}
+}
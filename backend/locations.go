@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ============================================================================
+// LocationProvider
+// ============================================================================
+
+// LocationProvider resuelve coordenadas de provincia/cantón y expone el
+// catálogo completo disponible. Permite sustituir el mapa de provincias
+// hardcodeado por un dataset externo sin recompilar.
+type LocationProvider interface {
+	Lookup(provincia, canton string) (Coordinates, error)
+	List() map[string][]string
+	Version() string
+}
+
+// ReloadableLocationProvider es implementado por los providers que pueden
+// releer su dataset en caliente (p. ej. el que carga desde archivo).
+type ReloadableLocationProvider interface {
+	LocationProvider
+	Reload() error
+}
+
+// locationProvider es la instancia activa, seleccionada en initLocationProvider.
+var locationProvider LocationProvider = NewStaticLocationProvider()
+
+// initLocationProvider elige StaticLocationProvider o FileLocationProvider
+// según la variable de entorno LOCATIONS_FILE.
+func initLocationProvider() {
+	path := getEnv("LOCATIONS_FILE", "")
+	if path == "" {
+		return
+	}
+	provider, err := NewFileLocationProvider(path)
+	if err != nil {
+		log.Printf("No se pudo cargar LOCATIONS_FILE=%s, usando dataset estático: %v", path, err)
+		return
+	}
+	locationProvider = provider
+	log.Printf("LocationProvider: archivo %s (versión %s)", path, provider.Version())
+}
+
+// ============================================================================
+// StaticLocationProvider (dataset por defecto, embebido en el binario)
+// ============================================================================
+
+// staticProvinciaCoordinates son las coordenadas aproximadas de las cuatro
+// provincias soportadas originalmente.
+var staticProvinciaCoordinates = map[string]map[string]Coordinates{
+	"Guayas": {
+		"Guayaquil":   {Lat: -2.1894, Lng: -79.8891},
+		"Durán":       {Lat: -2.1678, Lng: -79.8311},
+		"Samborondón": {Lat: -1.9633, Lng: -79.7239},
+		"Daule":       {Lat: -1.8614, Lng: -79.9781},
+		"Milagro":     {Lat: -2.1347, Lng: -79.5872},
+	},
+	"Pichincha": {
+		"Quito":         {Lat: -0.1807, Lng: -78.4678},
+		"Cayambe":       {Lat: 0.0389, Lng: -78.1422},
+		"Rumiñahui":     {Lat: -0.3128, Lng: -78.4428},
+		"Mejía":         {Lat: -0.5167, Lng: -78.5500},
+		"Pedro Moncayo": {Lat: 0.0833, Lng: -78.2667},
+	},
+	"Azuay": {
+		"Cuenca":   {Lat: -2.9001, Lng: -79.0059},
+		"Gualaceo": {Lat: -2.8833, Lng: -78.7833},
+		"Paute":    {Lat: -2.7833, Lng: -78.7500},
+		"Sigsig":   {Lat: -3.0500, Lng: -78.7833},
+		"Girón":    {Lat: -3.1500, Lng: -79.1333},
+	},
+	"Manabí": {
+		"Portoviejo":  {Lat: -1.0544, Lng: -80.4522},
+		"Manta":       {Lat: -0.9537, Lng: -80.7333},
+		"Chone":       {Lat: -0.6961, Lng: -80.0967},
+		"Jipijapa":    {Lat: -1.3500, Lng: -80.5833},
+		"Montecristi": {Lat: -1.0472, Lng: -80.6617},
+	},
+}
+
+// StaticLocationProvider sirve el dataset embebido de provincias/cantones.
+type StaticLocationProvider struct {
+	data map[string]map[string]Coordinates
+}
+
+// NewStaticLocationProvider crea un provider respaldado por el dataset embebido.
+func NewStaticLocationProvider() *StaticLocationProvider {
+	return &StaticLocationProvider{data: staticProvinciaCoordinates}
+}
+
+// Lookup busca las coordenadas de un cantón dentro de una provincia.
+func (p *StaticLocationProvider) Lookup(provincia, canton string) (Coordinates, error) {
+	cantones, exists := p.data[provincia]
+	if !exists {
+		return Coordinates{}, fmt.Errorf("provincia no encontrada: %s", provincia)
+	}
+	coords, exists := cantones[canton]
+	if !exists {
+		return Coordinates{}, fmt.Errorf("cantón no encontrado: %s en %s", canton, provincia)
+	}
+	return coords, nil
+}
+
+// List devuelve el catálogo completo de provincias y sus cantones.
+func (p *StaticLocationProvider) List() map[string][]string {
+	result := make(map[string][]string, len(p.data))
+	for provincia, cantones := range p.data {
+		list := make([]string, 0, len(cantones))
+		for canton := range cantones {
+			list = append(list, canton)
+		}
+		result[provincia] = list
+	}
+	return result
+}
+
+// Version identifica este dataset en /api/health y PredictResponse.
+func (p *StaticLocationProvider) Version() string {
+	return "static-v1"
+}
+
+// ============================================================================
+// FileLocationProvider (GeoJSON o CSV, cargado desde LOCATIONS_FILE)
+// ============================================================================
+
+// FileLocationProvider carga el catálogo de provincias/cantones desde un
+// archivo GeoJSON o CSV en disco, y admite recarga en caliente.
+type FileLocationProvider struct {
+	mu      sync.RWMutex
+	path    string
+	data    map[string]map[string]Coordinates
+	version string
+}
+
+// NewFileLocationProvider crea el provider y realiza la carga inicial.
+func NewFileLocationProvider(path string) (*FileLocationProvider, error) {
+	p := &FileLocationProvider{path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload vuelve a leer el archivo de datos y reemplaza el dataset en memoria
+// de forma atómica.
+func (p *FileLocationProvider) Reload() error {
+	data, err := loadLocationsFile(p.path)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.data = data
+	p.version = fmt.Sprintf("file-%d", time.Now().UnixNano())
+	p.mu.Unlock()
+	return nil
+}
+
+// Lookup busca las coordenadas de un cantón dentro de una provincia.
+func (p *FileLocationProvider) Lookup(provincia, canton string) (Coordinates, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	cantones, exists := p.data[provincia]
+	if !exists {
+		return Coordinates{}, fmt.Errorf("provincia no encontrada: %s", provincia)
+	}
+	coords, exists := cantones[canton]
+	if !exists {
+		return Coordinates{}, fmt.Errorf("cantón no encontrado: %s en %s", canton, provincia)
+	}
+	return coords, nil
+}
+
+// List devuelve el catálogo completo de provincias y sus cantones.
+func (p *FileLocationProvider) List() map[string][]string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	result := make(map[string][]string, len(p.data))
+	for provincia, cantones := range p.data {
+		list := make([]string, 0, len(cantones))
+		for canton := range cantones {
+			list = append(list, canton)
+		}
+		result[provincia] = list
+	}
+	return result
+}
+
+// Version identifica la versión del dataset cargado actualmente.
+func (p *FileLocationProvider) Version() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.version
+}
+
+// loadLocationsFile despacha a un parser CSV o GeoJSON según la extensión.
+func loadLocationsFile(path string) (map[string]map[string]Coordinates, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return loadLocationsCSV(path)
+	case ".geojson", ".json":
+		return loadLocationsGeoJSON(path)
+	default:
+		return nil, fmt.Errorf("extensión de LOCATIONS_FILE no soportada: %s", path)
+	}
+}
+
+// loadLocationsCSV lee un archivo con columnas provincia,canton,lat,lng.
+func loadLocationsCSV(path string) (map[string]map[string]Coordinates, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo LOCATIONS_FILE: %w", err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1 // filas con distinto número de columnas no deben abortar la carga completa
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo CSV de LOCATIONS_FILE: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("LOCATIONS_FILE está vacío")
+	}
+
+	data := make(map[string]map[string]Coordinates)
+	for _, row := range rows[1:] { // saltar encabezado
+		if len(row) < 4 {
+			continue
+		}
+		provincia, canton := row[0], row[1]
+		lat, err := strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("latitud inválida para %s/%s: %w", provincia, canton, err)
+		}
+		lng, err := strconv.ParseFloat(row[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("longitud inválida para %s/%s: %w", provincia, canton, err)
+		}
+		if data[provincia] == nil {
+			data[provincia] = make(map[string]Coordinates)
+		}
+		data[provincia][canton] = Coordinates{Lat: lat, Lng: lng}
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("LOCATIONS_FILE no contiene ubicaciones válidas")
+	}
+	return data, nil
+}
+
+// geoJSONFeatureCollection modela el subconjunto de GeoJSON que nos interesa:
+// puntos con propiedades "provincia" y "canton".
+type geoJSONFeatureCollection struct {
+	Features []struct {
+		Properties struct {
+			Provincia string `json:"provincia"`
+			Canton    string `json:"canton"`
+		} `json:"properties"`
+		Geometry struct {
+			Type        string    `json:"type"`
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+}
+
+// loadLocationsGeoJSON lee un FeatureCollection de puntos con properties
+// {provincia, canton} y geometry.coordinates [lng, lat].
+func loadLocationsGeoJSON(path string) (map[string]map[string]Coordinates, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error abriendo LOCATIONS_FILE: %w", err)
+	}
+
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(raw, &collection); err != nil {
+		return nil, fmt.Errorf("error parseando GeoJSON de LOCATIONS_FILE: %w", err)
+	}
+
+	data := make(map[string]map[string]Coordinates)
+	for _, feature := range collection.Features {
+		if feature.Geometry.Type != "Point" || len(feature.Geometry.Coordinates) < 2 {
+			continue
+		}
+		provincia, canton := feature.Properties.Provincia, feature.Properties.Canton
+		if provincia == "" || canton == "" {
+			continue
+		}
+		if data[provincia] == nil {
+			data[provincia] = make(map[string]Coordinates)
+		}
+		data[provincia][canton] = Coordinates{
+			Lng: feature.Geometry.Coordinates[0],
+			Lat: feature.Geometry.Coordinates[1],
+		}
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("LOCATIONS_FILE no contiene ubicaciones válidas")
+	}
+	return data, nil
+}
+
+// ============================================================================
+// ADMIN: RECARGA EN CALIENTE
+// ============================================================================
+
+// adminSecret protege POST /api/admin/locations/reload. Si está vacío, el
+// endpoint rechaza todas las peticiones.
+var adminSecret = getEnv("ADMIN_SECRET", "")
+
+// ReloadLocationsHandler maneja POST /api/admin/locations/reload, protegido
+// por el header X-Admin-Secret.
+func ReloadLocationsHandler(c *gin.Context) {
+	if adminSecret == "" || c.GetHeader("X-Admin-Secret") != adminSecret {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "no autorizado"})
+		return
+	}
+
+	reloadable, ok := locationProvider.(ReloadableLocationProvider)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "el proveedor de ubicaciones actual no soporta recarga en caliente",
+		})
+		return
+	}
+
+	if err := reloadable.Reload(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"version": locationProvider.Version(),
+	})
+}
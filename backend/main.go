@@ -11,11 +11,13 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -93,13 +95,14 @@ type AIServiceResponse struct {
 
 // PredictResponse representa la respuesta al frontend
 type PredictResponse struct {
-	Success      bool               `json:"success"`
-	Timestamp    string             `json:"timestamp"`
-	Location     Location           `json:"location"`
-	Predictions  []EventProbability `json:"predictions"`
-	ModelVersion string             `json:"model_version"`
-	IsMock       bool               `json:"is_mock"`
-	RequestID    string             `json:"request_id"`
+	Success        bool               `json:"success"`
+	Timestamp      string             `json:"timestamp"`
+	Location       Location           `json:"location"`
+	Predictions    []EventProbability `json:"predictions"`
+	ModelVersion   string             `json:"model_version"`
+	IsMock         bool               `json:"is_mock"`
+	RequestID      string             `json:"request_id"`
+	DatasetVersion string             `json:"dataset_version"`
 }
 
 // QueryLog estructura para logging de consultas (preparado para Firestore)
@@ -114,67 +117,23 @@ type QueryLog struct {
 	ResponseTime float64   `json:"response_time_ms"`
 }
 
-// ============================================================================
-// DATOS DE PROVINCIAS Y COORDENADAS
-// ============================================================================
-
-// Coordenadas aproximadas de las provincias de Ecuador
-var provinciaCoordinates = map[string]map[string]Coordinates{
-	"Guayas": {
-		"Guayaquil":      {Lat: -2.1894, Lng: -79.8891},
-		"Durán":          {Lat: -2.1678, Lng: -79.8311},
-		"Samborondón":    {Lat: -1.9633, Lng: -79.7239},
-		"Daule":          {Lat: -1.8614, Lng: -79.9781},
-		"Milagro":        {Lat: -2.1347, Lng: -79.5872},
-	},
-	"Pichincha": {
-		"Quito":           {Lat: -0.1807, Lng: -78.4678},
-		"Cayambe":         {Lat: 0.0389, Lng: -78.1422},
-		"Rumiñahui":       {Lat: -0.3128, Lng: -78.4428},
-		"Mejía":           {Lat: -0.5167, Lng: -78.5500},
-		"Pedro Moncayo":   {Lat: 0.0833, Lng: -78.2667},
-	},
-	"Azuay": {
-		"Cuenca":         {Lat: -2.9001, Lng: -79.0059},
-		"Gualaceo":       {Lat: -2.8833, Lng: -78.7833},
-		"Paute":          {Lat: -2.7833, Lng: -78.7500},
-		"Sigsig":         {Lat: -3.0500, Lng: -78.7833},
-		"Girón":          {Lat: -3.1500, Lng: -79.1333},
-	},
-	"Manabí": {
-		"Portoviejo":     {Lat: -1.0544, Lng: -80.4522},
-		"Manta":          {Lat: -0.9537, Lng: -80.7333},
-		"Chone":          {Lat: -0.6961, Lng: -80.0967},
-		"Jipijapa":       {Lat: -1.3500, Lng: -80.5833},
-		"Montecristi":    {Lat: -1.0472, Lng: -80.6617},
-	},
-}
-
 // ============================================================================
 // VALIDACIÓN
 // ============================================================================
+//
+// Las provincias y cantones disponibles los sirve locationProvider (ver
+// locations.go), que por defecto usa el dataset estático embebido y puede
+// sustituirse por uno cargado desde LOCATIONS_FILE.
 
 // ValidateProvinciaCantonCombo valida que el cantón pertenezca a la provincia
 func ValidateProvinciaCantonCombo(provincia, canton string) bool {
-	cantones, exists := provinciaCoordinates[provincia]
-	if !exists {
-		return false
-	}
-	_, cantonExists := cantones[canton]
-	return cantonExists
+	_, err := locationProvider.Lookup(provincia, canton)
+	return err == nil
 }
 
 // GetCoordinates obtiene las coordenadas para una provincia y cantón
 func GetCoordinates(provincia, canton string) (Coordinates, error) {
-	cantones, exists := provinciaCoordinates[provincia]
-	if !exists {
-		return Coordinates{}, fmt.Errorf("provincia no encontrada: %s", provincia)
-	}
-	coords, cantonExists := cantones[canton]
-	if !cantonExists {
-		return Coordinates{}, fmt.Errorf("cantón no encontrado: %s en %s", canton, provincia)
-	}
-	return coords, nil
+	return locationProvider.Lookup(provincia, canton)
 }
 
 // ============================================================================
@@ -183,69 +142,112 @@ func GetCoordinates(provincia, canton string) (Coordinates, error) {
 
 // HealthHandler maneja el endpoint de health check
 func HealthHandler(c *gin.Context) {
+	hits, misses, size := responseCache.Stats()
+	breakerStateStr, breakerFailures, breakerLastErr := circuitBreaker.Status()
 	c.JSON(http.StatusOK, gin.H{
-		"status":    "healthy",
-		"service":   "backend-api",
-		"timestamp": time.Now().Format(time.RFC3339),
+		"status":         "healthy",
+		"service":        "backend-api",
+		"timestamp":      time.Now().Format(time.RFC3339),
 		"ai_service_url": aiServiceURL,
+		"cache": gin.H{
+			"hits":   hits,
+			"misses": misses,
+			"size":   size,
+		},
+		"circuit_breaker": gin.H{
+			"state":      breakerStateStr,
+			"failures":   breakerFailures,
+			"last_error": breakerLastErr,
+		},
+		"dataset_version": locationProvider.Version(),
 	})
 }
 
 // PredictHandler maneja las peticiones de predicción
 func PredictHandler(c *gin.Context) {
-	startTime := time.Now()
-	requestID := fmt.Sprintf("req_%d", time.Now().UnixNano())
-
 	// Parsear el body de la petición
 	var req PredictRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+	if !bindJSONWithSizeLimit(c, &req) {
+		return
+	}
+
+	response, status, err := processPredictRequest(req)
+	if err != nil {
+		c.JSON(status, gin.H{
 			"success": false,
-			"error":   "Datos de entrada inválidos",
+			"error":   "Error al procesar la predicción",
 			"details": err.Error(),
 		})
 		return
 	}
 
+	c.JSON(http.StatusOK, response)
+}
+
+// processPredictRequest ejecuta el flujo completo de una predicción
+// (validación, coordenadas, caché, llamada resiliente al servicio de IA y
+// logging) y es compartido por PredictHandler y BatchPredictHandler.
+func processPredictRequest(req PredictRequest) (*PredictResponse, int, error) {
+	startTime := time.Now()
+	requestID := fmt.Sprintf("req_%d", time.Now().UnixNano())
+
 	// Validar combinación provincia-cantón
 	if !ValidateProvinciaCantonCombo(req.Provincia, req.Canton) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   fmt.Sprintf("Combinación inválida: el cantón '%s' no pertenece a la provincia '%s'", req.Canton, req.Provincia),
-		})
-		return
+		return nil, http.StatusBadRequest, fmt.Errorf("combinación inválida: el cantón '%s' no pertenece a la provincia '%s'", req.Canton, req.Provincia)
 	}
 
 	// Obtener coordenadas
 	coords, err := GetCoordinates(req.Provincia, req.Canton)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   err.Error(),
-		})
-		return
+		return nil, http.StatusBadRequest, err
 	}
 
-	// Preparar petición al servicio de IA
-	aiRequest := AIServiceRequest{
-		Latitude:  coords.Lat,
-		Longitude: coords.Lng,
-		Day:       req.Day,
-		Month:     req.Month,
-		Provincia: req.Provincia,
-		Canton:    req.Canton,
-	}
+	// Registrar la petición si llegó en un minuto pico, para precalentar el
+	// caché antes del siguiente ciclo.
+	recordPeakRequest(req)
+
+	// Servir desde caché si tenemos una respuesta reciente para esta combinación.
+	key := cacheKey(req)
+	var aiResponse *AIServiceResponse
+	if cached, ok := responseCache.Get(key); ok {
+		aiResponse = &AIServiceResponse{
+			Success:      cached.Success,
+			Timestamp:    cached.Timestamp,
+			Location:     cached.Location,
+			Predictions:  cached.Predictions,
+			ModelVersion: cached.ModelVersion,
+			IsMock:       cached.IsMock,
+		}
+	} else {
+		// Preparar petición al servicio de IA
+		aiRequest := AIServiceRequest{
+			Latitude:  coords.Lat,
+			Longitude: coords.Lng,
+			Day:       req.Day,
+			Month:     req.Month,
+			Provincia: req.Provincia,
+			Canton:    req.Canton,
+		}
 
-	// Llamar al servicio de IA
-	aiResponse, err := callAIService(aiRequest)
-	if err != nil {
-		log.Printf("Error llamando al servicio de IA: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "Error al procesar la predicción",
-			"details": err.Error(),
+		// Llamar al servicio de IA (con retry y circuit breaker)
+		aiResponse, err = callAIServiceWithResilience(requestID, aiRequest)
+		if err != nil {
+			log.Printf("Error llamando al servicio de IA: %v", err)
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrCircuitOpen) {
+				status = http.StatusServiceUnavailable
+			}
+			return nil, status, err
+		}
+
+		responseCache.Set(key, PredictResponse{
+			Success:      aiResponse.Success,
+			Timestamp:    aiResponse.Timestamp,
+			Location:     aiResponse.Location,
+			Predictions:  aiResponse.Predictions,
+			ModelVersion: aiResponse.ModelVersion,
+			IsMock:       aiResponse.IsMock,
 		})
-		return
 	}
 
 	// Calcular tiempo de respuesta
@@ -262,24 +264,23 @@ func PredictHandler(c *gin.Context) {
 		Success:      aiResponse.Success,
 		ResponseTime: responseTime,
 	}
-	
-	// TODO: Guardar en Firestore cuando esté configurado
-	// Ejemplo futuro:
-	// firestoreClient.Collection("query_logs").Doc(requestID).Set(ctx, queryLog)
-	log.Printf("Query Log: %+v", queryLog)
 
-	// Preparar y enviar respuesta
-	response := PredictResponse{
-		Success:      aiResponse.Success,
-		Timestamp:    aiResponse.Timestamp,
-		Location:     aiResponse.Location,
-		Predictions:  aiResponse.Predictions,
-		ModelVersion: aiResponse.ModelVersion,
-		IsMock:       aiResponse.IsMock,
-		RequestID:    requestID,
+	// Persistir el log de forma asíncrona (Firestore o memoria, según config)
+	enqueueQueryLog(queryLog)
+
+	// Preparar y devolver la respuesta
+	response := &PredictResponse{
+		Success:        aiResponse.Success,
+		Timestamp:      aiResponse.Timestamp,
+		Location:       aiResponse.Location,
+		Predictions:    aiResponse.Predictions,
+		ModelVersion:   aiResponse.ModelVersion,
+		IsMock:         aiResponse.IsMock,
+		RequestID:      requestID,
+		DatasetVersion: locationProvider.Version(),
 	}
 
-	c.JSON(http.StatusOK, response)
+	return response, http.StatusOK, nil
 }
 
 // callAIService hace la petición HTTP al servicio de IA
@@ -314,7 +315,10 @@ func callAIService(request AIServiceRequest) (*AIServiceResponse, error) {
 
 	// Verificar código de estado
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("servicio de IA retornó error %d: %s", resp.StatusCode, string(body))
+		return nil, &aiServiceStatusError{
+			StatusCode: resp.StatusCode,
+			err:        fmt.Errorf("servicio de IA retornó error %d: %s", resp.StatusCode, string(body)),
+		}
 	}
 
 	// Deserializar la respuesta
@@ -328,22 +332,137 @@ func callAIService(request AIServiceRequest) (*AIServiceResponse, error) {
 
 // GetProvinciasHandler devuelve la lista de provincias y cantones disponibles
 func GetProvinciasHandler(c *gin.Context) {
-	provincias := make(map[string][]string)
-	
-	for provincia, cantones := range provinciaCoordinates {
-		cantonList := make([]string, 0, len(cantones))
-		for canton := range cantones {
-			cantonList = append(cantonList, canton)
+	c.JSON(http.StatusOK, gin.H{
+		"success":         true,
+		"provincias":      locationProvider.List(),
+		"dataset_version": locationProvider.Version(),
+	})
+}
+
+// ListLogsHandler maneja GET /api/logs, devolviendo el historial de
+// consultas paginado, opcionalmente filtrado por provincia/cantón/rango.
+func ListLogsHandler(c *gin.Context) {
+	filter := QueryLogFilter{
+		Provincia: c.Query("provincia"),
+		Canton:    c.Query("canton"),
+		Cursor:    c.Query("cursor"),
+	}
+
+	if from := c.Query("from"); from != "" {
+		parsed, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "parámetro 'from' inválido, use RFC3339"})
+			return
 		}
-		provincias[provincia] = cantonList
+		filter.From = parsed
 	}
-	
+	if to := c.Query("to"); to != "" {
+		parsed, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "parámetro 'to' inválido, use RFC3339"})
+			return
+		}
+		filter.To = parsed
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "parámetro 'limit' inválido"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	logs, nextCursor, err := queryLogStore.List(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"success":    true,
-		"provincias": provincias,
+		"success":     true,
+		"logs":        logs,
+		"next_cursor": nextCursor,
 	})
 }
 
+// GetLogHandler maneja GET /api/logs/:request_id, devolviendo un único log.
+func GetLogHandler(c *gin.Context) {
+	requestID := c.Param("request_id")
+	logs, _, err := queryLogStore.List(c.Request.Context(), QueryLogFilter{RequestID: requestID, Limit: 1})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if len(logs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "query log no encontrado"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "log": logs[0]})
+}
+
+// statsKey agrupa las estadísticas agregadas por provincia y cantón.
+type statsKey struct {
+	Provincia string
+	Canton    string
+}
+
+// statsPageSize acota cuántos logs se leen del store por página al calcular
+// agregados, para no cargar todo el histórico en memoria de una sola vez.
+const statsPageSize = 500
+
+// statsAggregate acumula el conteo y tiempo de respuesta total de un grupo
+// provincia/cantón mientras se recorren las páginas de logs.
+type statsAggregate struct {
+	count       int
+	totalRespMs float64
+}
+
+// StatsHandler maneja GET /api/stats, devolviendo conteos y tiempo de
+// respuesta promedio agrupados por provincia/cantón. Pagina sobre el store
+// en vez de pedir un tope fijo de logs, para no truncar silenciosamente el
+// agregado ni leer de más contra backends como Firestore.
+func StatsHandler(c *gin.Context) {
+	totals := make(map[statsKey]*statsAggregate)
+
+	cursor := ""
+	for {
+		logs, nextCursor, err := queryLogStore.List(c.Request.Context(), QueryLogFilter{Limit: statsPageSize, Cursor: cursor})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		for _, entry := range logs {
+			key := statsKey{Provincia: entry.Provincia, Canton: entry.Canton}
+			agg, ok := totals[key]
+			if !ok {
+				agg = &statsAggregate{}
+				totals[key] = agg
+			}
+			agg.count++
+			agg.totalRespMs += entry.ResponseTime
+		}
+
+		if nextCursor == "" || len(logs) == 0 {
+			break
+		}
+		cursor = nextCursor
+	}
+
+	stats := make([]gin.H, 0, len(totals))
+	for key, agg := range totals {
+		stats = append(stats, gin.H{
+			"provincia":            key.Provincia,
+			"canton":               key.Canton,
+			"count":                agg.count,
+			"avg_response_time_ms": agg.totalRespMs / float64(agg.count),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "stats": stats})
+}
+
 // ============================================================================
 // MAIN
 // ============================================================================
@@ -355,6 +474,15 @@ func main() {
 		gin.SetMode(gin.DebugMode)
 	}
 
+	// Iniciar el scheduler de precalentamiento de caché
+	startPrefetchScheduler()
+
+	// Inicializar el store de query logs (Firestore o memoria) y su worker pool
+	initQueryLogStore()
+
+	// Inicializar el proveedor de ubicaciones (estático o desde LOCATIONS_FILE)
+	initLocationProvider()
+
 	// Crear router
 	r := gin.Default()
 
@@ -376,7 +504,12 @@ func main() {
 	r.GET("/health", HealthHandler)
 	r.GET("/api/health", HealthHandler)
 	r.GET("/api/provincias", GetProvinciasHandler)
-	r.POST("/api/predict", PredictHandler)
+	r.POST("/api/predict", MaxRecvSizeMiddleware(), PredictHandler)
+	r.POST("/api/predict/batch", MaxRecvSizeMiddleware(), BatchPredictHandler)
+	r.GET("/api/logs", ListLogsHandler)
+	r.GET("/api/logs/:request_id", GetLogHandler)
+	r.GET("/api/stats", StatsHandler)
+	r.POST("/api/admin/locations/reload", ReloadLocationsHandler)
 
 	// Ruta raíz
 	r.GET("/", func(c *gin.Context) {
@@ -384,9 +517,14 @@ func main() {
 			"service": "Backend API - Sistema de Predicción de Eventos Catastróficos",
 			"version": "1.0.0",
 			"endpoints": gin.H{
-				"health":     "GET /api/health",
-				"provincias": "GET /api/provincias",
-				"predict":    "POST /api/predict",
+				"health":          "GET /api/health",
+				"provincias":      "GET /api/provincias",
+				"predict":         "POST /api/predict",
+				"predictBatch":    "POST /api/predict/batch",
+				"logs":            "GET /api/logs",
+				"log":             "GET /api/logs/:request_id",
+				"stats":           "GET /api/stats",
+				"reloadLocations": "POST /api/admin/locations/reload",
 			},
 		})
 	})
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// ============================================================================
+// QueryLogStore
+// ============================================================================
+
+// QueryLogFilter acota los resultados devueltos por QueryLogStore.List.
+// Cursor es opaco para el llamador: se obtiene del resultado anterior y se
+// reenvía tal cual para pedir la siguiente página.
+type QueryLogFilter struct {
+	RequestID string
+	Provincia string
+	Canton    string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Cursor    string
+}
+
+// QueryLogStore persiste y consulta el historial de peticiones de predicción.
+type QueryLogStore interface {
+	Save(ctx context.Context, entry QueryLog) error
+	List(ctx context.Context, filter QueryLogFilter) (entries []QueryLog, nextCursor string, err error)
+}
+
+// ============================================================================
+// Implementación en memoria (usada en tests y como fallback sin Firestore)
+// ============================================================================
+
+// InMemoryQueryLogStore guarda los logs en un slice protegido por mutex.
+type InMemoryQueryLogStore struct {
+	mu   sync.Mutex
+	logs []QueryLog
+}
+
+// NewInMemoryQueryLogStore crea un store en memoria vacío.
+func NewInMemoryQueryLogStore() *InMemoryQueryLogStore {
+	return &InMemoryQueryLogStore{}
+}
+
+// Save agrega el log a la lista en memoria.
+func (s *InMemoryQueryLogStore) Save(ctx context.Context, entry QueryLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logs = append(s.logs, entry)
+	return nil
+}
+
+// List filtra y pagina los logs en memoria, ordenados por timestamp
+// descendente (más reciente primero).
+func (s *InMemoryQueryLogStore) List(ctx context.Context, filter QueryLogFilter) ([]QueryLog, string, error) {
+	s.mu.Lock()
+	all := make([]QueryLog, len(s.logs))
+	copy(all, s.logs)
+	s.mu.Unlock()
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Timestamp.After(all[j].Timestamp) })
+
+	matched := make([]QueryLog, 0, len(all))
+	for _, entry := range all {
+		if matchesQueryLogFilter(entry, filter) {
+			matched = append(matched, entry)
+		}
+	}
+
+	offset := 0
+	if filter.Cursor != "" {
+		if n, err := strconv.Atoi(filter.Cursor); err == nil {
+			offset = n
+		}
+	}
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	end := offset + limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	page := matched[offset:end]
+	nextCursor := ""
+	if end < len(matched) {
+		nextCursor = strconv.Itoa(end)
+	}
+	return page, nextCursor, nil
+}
+
+// matchesQueryLogFilter aplica los criterios de QueryLogFilter a un log.
+func matchesQueryLogFilter(entry QueryLog, filter QueryLogFilter) bool {
+	if filter.RequestID != "" && entry.RequestID != filter.RequestID {
+		return false
+	}
+	if filter.Provincia != "" && entry.Provincia != filter.Provincia {
+		return false
+	}
+	if filter.Canton != "" && entry.Canton != filter.Canton {
+		return false
+	}
+	if !filter.From.IsZero() && entry.Timestamp.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && entry.Timestamp.After(filter.To) {
+		return false
+	}
+	return true
+}
+
+// ============================================================================
+// Implementación Firestore
+// ============================================================================
+
+// FirestoreQueryLogStore persiste los logs en una colección de Firestore.
+type FirestoreQueryLogStore struct {
+	client     *firestore.Client
+	collection string
+}
+
+// NewFirestoreQueryLogStore abre un cliente de Firestore para el proyecto y
+// colección indicados.
+func NewFirestoreQueryLogStore(ctx context.Context, projectID, collection string) (*FirestoreQueryLogStore, error) {
+	client, err := firestore.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("error creando cliente de Firestore: %w", err)
+	}
+	return &FirestoreQueryLogStore{client: client, collection: collection}, nil
+}
+
+// Save escribe el log como un documento identificado por su RequestID.
+func (s *FirestoreQueryLogStore) Save(ctx context.Context, entry QueryLog) error {
+	_, err := s.client.Collection(s.collection).Doc(entry.RequestID).Set(ctx, entry)
+	if err != nil {
+		return fmt.Errorf("error guardando query log en Firestore: %w", err)
+	}
+	return nil
+}
+
+// List consulta la colección aplicando los filtros disponibles, ordenando
+// por timestamp descendente y paginando con un cursor basado en el último
+// timestamp devuelto.
+func (s *FirestoreQueryLogStore) List(ctx context.Context, filter QueryLogFilter) ([]QueryLog, string, error) {
+	if filter.RequestID != "" {
+		doc, err := s.client.Collection(s.collection).Doc(filter.RequestID).Get(ctx)
+		if err != nil {
+			return nil, "", fmt.Errorf("error leyendo query log de Firestore: %w", err)
+		}
+		var entry QueryLog
+		if err := doc.DataTo(&entry); err != nil {
+			return nil, "", fmt.Errorf("error deserializando query log: %w", err)
+		}
+		return []QueryLog{entry}, "", nil
+	}
+
+	// Se ordena por Timestamp y, como desempate, por RequestID: así el cursor
+	// compuesto (timestamp + request_id) identifica una posición exacta en el
+	// resultado y no se saltan entradas que comparten el mismo Timestamp.
+	query := s.client.Collection(s.collection).
+		OrderBy("Timestamp", firestore.Desc).
+		OrderBy("RequestID", firestore.Desc)
+	if filter.Provincia != "" {
+		query = query.Where("Provincia", "==", filter.Provincia)
+	}
+	if filter.Canton != "" {
+		query = query.Where("Canton", "==", filter.Canton)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("Timestamp", ">=", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("Timestamp", "<=", filter.To)
+	}
+	if filter.Cursor != "" {
+		if cursorTime, cursorRequestID, ok := decodeFirestoreCursor(filter.Cursor); ok {
+			query = query.StartAfter(cursorTime, cursorRequestID)
+		}
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	query = query.Limit(limit + 1)
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return nil, "", fmt.Errorf("error listando query logs de Firestore: %w", err)
+	}
+
+	entries := make([]QueryLog, 0, len(docs))
+	for _, doc := range docs {
+		var entry QueryLog
+		if err := doc.DataTo(&entry); err != nil {
+			return nil, "", fmt.Errorf("error deserializando query log: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	nextCursor := ""
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[limit-1]
+		nextCursor = encodeFirestoreCursor(last.Timestamp, last.RequestID)
+	}
+	return entries, nextCursor, nil
+}
+
+// encodeFirestoreCursor y decodeFirestoreCursor serializan la posición de
+// paginación como "timestamp|request_id", usando RequestID como desempate
+// para entradas con el mismo Timestamp.
+func encodeFirestoreCursor(ts time.Time, requestID string) string {
+	return ts.Format(time.RFC3339Nano) + "|" + requestID
+}
+
+func decodeFirestoreCursor(cursor string) (time.Time, string, bool) {
+	parts := strings.SplitN(cursor, "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return ts, parts[1], true
+}
+
+// ============================================================================
+// ESCRITURA ASÍNCRONA CON WORKER POOL ACOTADO
+// ============================================================================
+
+var queryLogStore QueryLogStore = NewInMemoryQueryLogStore()
+
+var queryLogQueue = make(chan QueryLog, getEnvInt("QUERYLOG_QUEUE_SIZE", 256))
+
+// initQueryLogStore elige la implementación de QueryLogStore según la
+// configuración del entorno y arranca el pool de workers que consume
+// queryLogQueue.
+func initQueryLogStore() {
+	projectID := getEnv("FIRESTORE_PROJECT_ID", "")
+	if projectID != "" {
+		collection := getEnv("FIRESTORE_COLLECTION", "query_logs")
+		store, err := NewFirestoreQueryLogStore(context.Background(), projectID, collection)
+		if err != nil {
+			log.Printf("No se pudo inicializar Firestore, usando store en memoria: %v", err)
+		} else {
+			queryLogStore = store
+			log.Printf("QueryLogStore: Firestore (proyecto=%s, colección=%s)", projectID, collection)
+		}
+	}
+
+	workers := getEnvInt("QUERYLOG_WORKERS", 4)
+	for i := 0; i < workers; i++ {
+		go queryLogWorker()
+	}
+}
+
+// queryLogWorker consume entradas de queryLogQueue y las persiste, sin
+// bloquear nunca el camino de la petición HTTP original.
+func queryLogWorker() {
+	for entry := range queryLogQueue {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := queryLogStore.Save(ctx, entry); err != nil {
+			log.Printf("Error guardando query log %s: %v", entry.RequestID, err)
+		}
+		cancel()
+	}
+}
+
+// enqueueQueryLog encola el log para persistencia asíncrona. Si la cola
+// está llena se descarta con un log de advertencia en vez de bloquear.
+func enqueueQueryLog(entry QueryLog) {
+	select {
+	case queryLogQueue <- entry:
+	default:
+		log.Printf("Cola de query logs llena, descartando log %s", entry.RequestID)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestInMemoryQueryLogStoreListFiltersAndPages(t *testing.T) {
+	store := NewInMemoryQueryLogStore()
+	ctx := context.Background()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	entries := []QueryLog{
+		{RequestID: "req_1", Timestamp: base, Provincia: "Guayas", Canton: "Guayaquil", ResponseTime: 10},
+		{RequestID: "req_2", Timestamp: base.Add(time.Minute), Provincia: "Guayas", Canton: "Durán", ResponseTime: 20},
+		{RequestID: "req_3", Timestamp: base.Add(2 * time.Minute), Provincia: "Pichincha", Canton: "Quito", ResponseTime: 30},
+	}
+	for _, entry := range entries {
+		if err := store.Save(ctx, entry); err != nil {
+			t.Fatalf("Save(%s) devolvió error: %v", entry.RequestID, err)
+		}
+	}
+
+	t.Run("filtra por provincia", func(t *testing.T) {
+		got, _, err := store.List(ctx, QueryLogFilter{Provincia: "Guayas"})
+		if err != nil {
+			t.Fatalf("List devolvió error: %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("esperaba 2 logs de Guayas, obtuve %d", len(got))
+		}
+	})
+
+	t.Run("filtra por request_id exacto", func(t *testing.T) {
+		got, _, err := store.List(ctx, QueryLogFilter{RequestID: "req_2"})
+		if err != nil {
+			t.Fatalf("List devolvió error: %v", err)
+		}
+		if len(got) != 1 || got[0].RequestID != "req_2" {
+			t.Fatalf("esperaba solo req_2, obtuve %+v", got)
+		}
+	})
+
+	t.Run("pagina con cursor y devuelve más reciente primero", func(t *testing.T) {
+		page1, cursor, err := store.List(ctx, QueryLogFilter{Limit: 2})
+		if err != nil {
+			t.Fatalf("List devolvió error: %v", err)
+		}
+		if len(page1) != 2 || cursor == "" {
+			t.Fatalf("esperaba página de 2 con cursor, obtuve %d logs, cursor=%q", len(page1), cursor)
+		}
+		if page1[0].RequestID != "req_3" {
+			t.Fatalf("esperaba req_3 primero (más reciente), obtuve %s", page1[0].RequestID)
+		}
+
+		page2, nextCursor, err := store.List(ctx, QueryLogFilter{Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatalf("List devolvió error: %v", err)
+		}
+		if len(page2) != 1 || nextCursor != "" {
+			t.Fatalf("esperaba 1 log restante sin cursor siguiente, obtuve %d logs, cursor=%q", len(page2), nextCursor)
+		}
+		if page2[0].RequestID != "req_1" {
+			t.Fatalf("esperaba req_1 en la última página, obtuve %s", page2[0].RequestID)
+		}
+	})
+
+	t.Run("filtra por rango de tiempo", func(t *testing.T) {
+		got, _, err := store.List(ctx, QueryLogFilter{From: base.Add(90 * time.Second), To: base.Add(3 * time.Minute)})
+		if err != nil {
+			t.Fatalf("List devolvió error: %v", err)
+		}
+		if len(got) != 1 || got[0].RequestID != "req_3" {
+			t.Fatalf("esperaba solo req_3 dentro del rango, obtuve %+v", got)
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ============================================================================
+// RETRY CON BACKOFF EXPONENCIAL
+// ============================================================================
+
+var (
+	retryMaxAttempts = getEnvInt("RETRY_MAX_ATTEMPTS", 3)
+	retryBaseDelay   = getEnvDurationMillis("RETRY_BASE_DELAY_MS", 200*time.Millisecond)
+)
+
+// getEnvInt lee una variable de entorno como entero, devolviendo
+// defaultValue si no está definida o es inválida.
+func getEnvInt(key string, defaultValue int) int {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return defaultValue
+	}
+	return n
+}
+
+// getEnvDurationMillis lee una variable de entorno como milisegundos
+// enteros, devolviendo defaultValue si no está definida o es inválida.
+func getEnvDurationMillis(key string, defaultValue time.Duration) time.Duration {
+	value := getEnv(key, "")
+	if value == "" {
+		return defaultValue
+	}
+	millis, err := strconv.Atoi(value)
+	if err != nil || millis <= 0 {
+		return defaultValue
+	}
+	return time.Duration(millis) * time.Millisecond
+}
+
+// aiServiceStatusError lleva el código de estado HTTP devuelto por el
+// servicio de IA, para que callAIServiceWithResilience pueda distinguir
+// errores reintentables (red, 5xx) de errores definitivos (4xx).
+type aiServiceStatusError struct {
+	StatusCode int
+	err        error
+}
+
+func (e *aiServiceStatusError) Error() string { return e.err.Error() }
+func (e *aiServiceStatusError) Unwrap() error { return e.err }
+
+// isRetriableAIServiceError indica si vale la pena reintentar el error:
+// errores de red/serialización (sin código de estado) y respuestas 5xx lo
+// son; respuestas 4xx son definitivas y no se reintentan.
+func isRetriableAIServiceError(err error) bool {
+	var statusErr *aiServiceStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+// ============================================================================
+// CIRCUIT BREAKER
+// ============================================================================
+
+// breakerState representa el estado del circuit breaker.
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// ErrCircuitOpen se devuelve cuando el circuit breaker está abierto y
+// rechaza la petición sin intentar llamar al servicio de IA.
+var ErrCircuitOpen = errors.New("circuit breaker abierto: servicio de IA no disponible temporalmente")
+
+// CircuitBreaker implementa un breaker de tres estados (closed/open/half-open)
+// sobre el conteo de fallos seguidos, acotado por una ventana de tiempo: si el
+// hueco desde el último fallo supera la ventana, el conteo se reinicia en
+// vez de acumular fallos separados por horas como si fueran consecutivos.
+type CircuitBreaker struct {
+	mu            sync.Mutex
+	state         breakerState
+	failures      int
+	threshold     int
+	window        time.Duration
+	cooldown      time.Duration
+	openedAt      time.Time
+	lastFailureAt time.Time
+	lastErr       error
+}
+
+// NewCircuitBreaker crea un breaker cerrado que se abre tras `threshold`
+// fallos seguidos, cada uno ocurrido a no más de `window` del anterior, y
+// permanece abierto durante `cooldown`.
+func NewCircuitBreaker(threshold int, window, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, window: window, cooldown: cooldown}
+}
+
+// Allow indica si se debe permitir un nuevo intento. Si el breaker está
+// abierto pero el cooldown ya pasó, pasa a half-open y permite un intento
+// de prueba.
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) >= b.cooldown {
+			b.state = breakerHalfOpen
+			return true
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// RecordSuccess cierra el breaker y reinicia el contador de fallos.
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = breakerClosed
+	b.failures = 0
+	b.lastErr = nil
+}
+
+// RecordFailure registra un fallo y abre el breaker si se alcanza el umbral
+// dentro de la ventana configurada (o si el intento de prueba en half-open
+// falló). Si el fallo anterior ocurrió fuera de la ventana, el conteo
+// arranca de nuevo en vez de seguir acumulando.
+func (b *CircuitBreaker) RecordFailure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.lastErr = err
+
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+		b.openedAt = now
+		b.failures = 1
+		b.lastFailureAt = now
+		return
+	}
+
+	if b.lastFailureAt.IsZero() || now.Sub(b.lastFailureAt) > b.window {
+		b.failures = 1
+	} else {
+		b.failures++
+	}
+	b.lastFailureAt = now
+
+	if b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = now
+	}
+}
+
+// Status devuelve el estado actual, el número de fallos de la racha actual
+// (se pone a cero con el siguiente éxito; el paso del tiempo sin nuevos
+// fallos no lo reinicia) y el último error registrado, para exponerlos en
+// /api/health.
+func (b *CircuitBreaker) Status() (state string, failures int, lastErr string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.lastErr != nil {
+		lastErr = b.lastErr.Error()
+	}
+	return b.state.String(), b.failures, lastErr
+}
+
+// circuitBreaker protege las llamadas al servicio de IA.
+var circuitBreaker = NewCircuitBreaker(
+	getEnvInt("BREAKER_FAILURE_THRESHOLD", 5),
+	getEnvDuration("BREAKER_FAILURE_WINDOW_SECONDS", 60*time.Second),
+	getEnvDuration("BREAKER_COOLDOWN_SECONDS", 30*time.Second),
+)
+
+// ============================================================================
+// callAIServiceWithResilience
+// ============================================================================
+
+// callAIServiceWithResilience envuelve callAIService con un circuit breaker y
+// una política de reintentos con backoff exponencial y jitter. Reintenta
+// sobre errores de red y respuestas 5xx; un error de validación (4xx) se
+// propaga de inmediato sin reintentar y sin contar como fallo del breaker.
+func callAIServiceWithResilience(requestID string, request AIServiceRequest) (*AIServiceResponse, error) {
+	if !circuitBreaker.Allow() {
+		log.Printf("[%s] circuit breaker abierto, rechazando petición", requestID)
+		return nil, ErrCircuitOpen
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= retryMaxAttempts; attempt++ {
+		resp, err := callAIService(request)
+		if err == nil {
+			circuitBreaker.RecordSuccess()
+			return resp, nil
+		}
+
+		if !isRetriableAIServiceError(err) {
+			log.Printf("[%s] error no reintentable del servicio de IA: %v", requestID, err)
+			return nil, err
+		}
+
+		lastErr = err
+		log.Printf("[%s] intento %d/%d contra el servicio de IA falló: %v", requestID, attempt, retryMaxAttempts, err)
+
+		if attempt == retryMaxAttempts {
+			break
+		}
+
+		delay := retryBaseDelay * time.Duration(1<<uint(attempt-1))
+		delay += time.Duration(rand.Int63n(int64(retryBaseDelay)))
+		time.Sleep(delay)
+	}
+
+	circuitBreaker.RecordFailure(lastErr)
+	return nil, fmt.Errorf("agotados %d intentos contra el servicio de IA: %w", retryMaxAttempts, lastErr)
+}
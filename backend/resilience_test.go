@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsRetriableAIServiceError(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		retriable bool
+	}{
+		{
+			name:      "error de red sin código de estado",
+			err:       errors.New("error ejecutando petición: connection refused"),
+			retriable: true,
+		},
+		{
+			name:      "5xx del servicio de IA",
+			err:       &aiServiceStatusError{StatusCode: http.StatusBadGateway, err: errors.New("bad gateway")},
+			retriable: true,
+		},
+		{
+			name:      "4xx del servicio de IA",
+			err:       &aiServiceStatusError{StatusCode: http.StatusBadRequest, err: errors.New("bad request")},
+			retriable: false,
+		},
+		{
+			name:      "4xx envuelto con fmt.Errorf",
+			err:       fmt.Errorf("contexto: %w", &aiServiceStatusError{StatusCode: http.StatusUnprocessableEntity, err: errors.New("entidad inválida")}),
+			retriable: false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isRetriableAIServiceError(tc.err); got != tc.retriable {
+				t.Fatalf("isRetriableAIServiceError() = %v, esperaba %v", got, tc.retriable)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerOpensAfterThresholdWithinWindow(t *testing.T) {
+	breaker := NewCircuitBreaker(2, time.Minute, 10*time.Millisecond)
+
+	breaker.RecordFailure(errors.New("falla 1"))
+	if state, _, _ := breaker.Status(); state != "closed" {
+		t.Fatalf("esperaba closed tras un solo fallo, obtuve %s", state)
+	}
+
+	breaker.RecordFailure(errors.New("falla 2"))
+	if state, failures, _ := breaker.Status(); state != "open" || failures != 2 {
+		t.Fatalf("esperaba open con 2 fallos, obtuve state=%s failures=%d", state, failures)
+	}
+
+	if breaker.Allow() {
+		t.Fatalf("esperaba que Allow() rechace mientras el breaker está abierto")
+	}
+
+	time.Sleep(15 * time.Millisecond)
+	if !breaker.Allow() {
+		t.Fatalf("esperaba que Allow() permita un intento de prueba tras el cooldown (half-open)")
+	}
+}
+
+func TestCircuitBreakerResetsCountAfterFailureWindowElapses(t *testing.T) {
+	breaker := NewCircuitBreaker(2, 10*time.Millisecond, time.Minute)
+
+	breaker.RecordFailure(errors.New("falla antigua"))
+	time.Sleep(20 * time.Millisecond)
+	breaker.RecordFailure(errors.New("falla nueva, fuera de ventana"))
+
+	if state, failures, _ := breaker.Status(); state != "closed" || failures != 1 {
+		t.Fatalf("esperaba que el conteo se reinicie fuera de la ventana, obtuve state=%s failures=%d", state, failures)
+	}
+}